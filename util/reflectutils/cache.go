@@ -0,0 +1,222 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reflectutils
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"yunion.io/x/pkg/utils"
+)
+
+// defaultStructInfoCacheSize bounds the number of distinct struct types the
+// process will keep SStructFieldInfo slices cached for. Override with
+// SetStructInfoCacheSize, e.g. for long-running services that register an
+// unbounded number of dynamic types.
+const defaultStructInfoCacheSize = 4096
+
+// structInfoCacheEntry is what structInfoCache stores per reflect.Type: the
+// field infos computed by fetchStructFieldInfos, plus the name->index remap
+// used by SStructFieldValueSetV2.getStructFieldIndex so lookups don't have
+// to re-scan the (immutable, once cached) infos slice every time.
+type structInfoCacheEntry struct {
+	typ     reflect.Type
+	infos   []SStructFieldInfo
+	indexOf map[string]int
+}
+
+// buildStructFieldIndex precomputes every name variant getStructFieldIndex
+// used to accept, mapped to the logical field index, so lookups become a
+// single map access instead of a linear scan. Fields are walked in the same
+// order as the baseline linear scan (promoted/embedded fields first), so on
+// a duplicate name the first field wins here too, rather than the last.
+func buildStructFieldIndex(infos []SStructFieldInfo) map[string]int {
+	idx := make(map[string]int, len(infos)*3)
+	set := func(key string, i int) {
+		if _, ok := idx[key]; !ok {
+			idx[key] = i
+		}
+	}
+	for i := range infos {
+		info := &infos[i]
+		set(info.MarshalName(), i)
+		set(utils.CamelSplit(info.FieldName, "_"), i)
+		set(info.FieldName, i)
+		set(decapitalize(info.FieldName), i)
+	}
+	return idx
+}
+
+func decapitalize(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// SCacheStats reports structInfoCache activity, for monitoring long-running
+// processes that register many dynamic types.
+type SCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Size      int
+}
+
+// structInfoLRU is a bounded, concurrency-safe, singleflight-style cache of
+// reflect.Type to *structInfoCacheEntry. Concurrent misses on the same type
+// block on a single in-flight computation rather than each redoing the
+// reflection walk.
+type structInfoLRU struct {
+	mu      sync.Mutex
+	maxSize int
+	ll      *list.List
+	items   map[reflect.Type]*list.Element
+	loading map[reflect.Type]chan struct{}
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+func newStructInfoLRU(maxSize int) *structInfoLRU {
+	return &structInfoLRU{
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   map[reflect.Type]*list.Element{},
+		loading: map[reflect.Type]chan struct{}{},
+	}
+}
+
+var structInfoCache = newStructInfoLRU(defaultStructInfoCacheSize)
+
+// get returns the cached entry for t, computing it with compute if this is
+// the first lookup for t. Concurrent callers racing on the same t share a
+// single compute call: the losers block on a channel instead of redoing the
+// work.
+func (c *structInfoLRU) get(t reflect.Type, compute func() *structInfoCacheEntry) *structInfoCacheEntry {
+	for {
+		c.mu.Lock()
+		if el, ok := c.items[t]; ok {
+			c.ll.MoveToFront(el)
+			atomic.AddInt64(&c.hits, 1)
+			entry := el.Value.(*structInfoCacheEntry)
+			c.mu.Unlock()
+			return entry
+		}
+		if ch, ok := c.loading[t]; ok {
+			c.mu.Unlock()
+			<-ch
+			continue
+		}
+		ch := make(chan struct{})
+		c.loading[t] = ch
+		atomic.AddInt64(&c.misses, 1)
+		c.mu.Unlock()
+
+		entry := compute()
+
+		c.mu.Lock()
+		el := c.ll.PushFront(entry)
+		c.items[t] = el
+		delete(c.loading, t)
+		close(ch)
+		c.evictLocked()
+		c.mu.Unlock()
+		return entry
+	}
+}
+
+func (c *structInfoLRU) evictLocked() {
+	for c.maxSize > 0 && c.ll.Len() > c.maxSize {
+		back := c.ll.Back()
+		if back == nil {
+			break
+		}
+		entry := back.Value.(*structInfoCacheEntry)
+		delete(c.items, entry.typ)
+		c.ll.Remove(back)
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+func (c *structInfoLRU) invalidate(t reflect.Type) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[t]; ok {
+		c.ll.Remove(el)
+		delete(c.items, t)
+	}
+}
+
+func (c *structInfoLRU) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll = list.New()
+	c.items = map[reflect.Type]*list.Element{}
+	atomic.StoreInt64(&c.hits, 0)
+	atomic.StoreInt64(&c.misses, 0)
+	atomic.StoreInt64(&c.evictions, 0)
+}
+
+func (c *structInfoLRU) setMaxSize(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxSize = n
+	c.evictLocked()
+}
+
+func (c *structInfoLRU) stats() SCacheStats {
+	c.mu.Lock()
+	size := c.ll.Len()
+	c.mu.Unlock()
+	return SCacheStats{
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Evictions: atomic.LoadInt64(&c.evictions),
+		Size:      size,
+	}
+}
+
+// SetStructInfoCacheSize bounds the number of struct types whose field infos
+// stay cached. A non-positive n disables eviction (the cache grows
+// unbounded, matching the previous sync.Map behavior).
+func SetStructInfoCacheSize(n int) {
+	structInfoCache.setMaxSize(n)
+}
+
+// CacheStats returns a point-in-time snapshot of the struct field info
+// cache's hit/miss/eviction counters and current size.
+func CacheStats() SCacheStats {
+	return structInfoCache.stats()
+}
+
+// InvalidateStructInfoCache drops the cached field infos for t, if any.
+// Useful for tests and for services that re-register a type's shape at
+// runtime (e.g. code generation, plugin loading).
+func InvalidateStructInfoCache(t reflect.Type) {
+	structInfoCache.invalidate(t)
+}
+
+// ResetStructInfoCache empties the struct field info cache and zeroes its
+// hit/miss/eviction counters.
+func ResetStructInfoCache() {
+	structInfoCache.reset()
+}
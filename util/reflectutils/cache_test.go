@@ -0,0 +1,123 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reflectutils
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type sCacheFieldsA struct {
+	Name string `json:"name"`
+}
+
+type sCacheFieldsB struct {
+	Value int `json:"value"`
+}
+
+func TestStructInfoCacheHitsAndInvalidate(t *testing.T) {
+	ResetStructInfoCache()
+	before := CacheStats()
+
+	FetchStructFieldValueSetV2(reflect.ValueOf(sCacheFieldsA{Name: "x"}))
+	afterMiss := CacheStats()
+	if afterMiss.Misses != before.Misses+1 {
+		t.Fatalf("expected one miss, got %d -> %d", before.Misses, afterMiss.Misses)
+	}
+
+	FetchStructFieldValueSetV2(reflect.ValueOf(sCacheFieldsA{Name: "y"}))
+	afterHit := CacheStats()
+	if afterHit.Hits != afterMiss.Hits+1 {
+		t.Fatalf("expected one hit, got %d -> %d", afterMiss.Hits, afterHit.Hits)
+	}
+
+	InvalidateStructInfoCache(reflect.TypeOf(sCacheFieldsA{}))
+	FetchStructFieldValueSetV2(reflect.ValueOf(sCacheFieldsA{Name: "z"}))
+	afterInvalidate := CacheStats()
+	if afterInvalidate.Misses != afterHit.Misses+1 {
+		t.Fatalf("expected a miss after invalidation, got %d -> %d", afterHit.Misses, afterInvalidate.Misses)
+	}
+}
+
+func TestStructInfoCacheEviction(t *testing.T) {
+	ResetStructInfoCache()
+	SetStructInfoCacheSize(1)
+	defer SetStructInfoCacheSize(defaultStructInfoCacheSize)
+
+	FetchStructFieldValueSetV2(reflect.ValueOf(sCacheFieldsA{}))
+	FetchStructFieldValueSetV2(reflect.ValueOf(sCacheFieldsB{}))
+	stats := CacheStats()
+	if stats.Size != 1 {
+		t.Fatalf("expected cache size bounded to 1, got %d", stats.Size)
+	}
+	if stats.Evictions != 1 {
+		t.Fatalf("expected one eviction, got %d", stats.Evictions)
+	}
+}
+
+type SCacheShadowInner struct {
+	Name string `json:"name"`
+}
+
+type sCacheShadowOuter struct {
+	SCacheShadowInner
+	Name string `json:"name"`
+}
+
+func TestBuildStructFieldIndexFirstWins(t *testing.T) {
+	ResetStructInfoCache()
+
+	outer := sCacheShadowOuter{
+		SCacheShadowInner: SCacheShadowInner{Name: "inner"},
+		Name:              "outer",
+	}
+	set := FetchStructFieldValueSetV2(reflect.ValueOf(outer))
+
+	// The embedded SCacheShadowInner.Name is flattened ahead of Outer's own
+	// Name field, so a linear scan (the pre-cache baseline behavior) finds it
+	// first; the cached index must resolve the same way rather than letting
+	// the later, shallower field win.
+	val, ok := set.GetValue("name")
+	if !ok {
+		t.Fatalf("expected to find field name")
+	}
+	if val.String() != "inner" {
+		t.Fatalf("expected first-wins resolution to return the embedded field, got %q", val.String())
+	}
+}
+
+func TestStructInfoCacheConcurrentMiss(t *testing.T) {
+	ResetStructInfoCache()
+
+	type sConcurrent struct {
+		A string
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			FetchStructFieldValueSetV2(reflect.ValueOf(sConcurrent{}))
+		}()
+	}
+	wg.Wait()
+
+	stats := CacheStats()
+	if stats.Misses != 1 {
+		t.Fatalf("expected exactly one miss across concurrent callers, got %d", stats.Misses)
+	}
+}
@@ -17,7 +17,6 @@ package reflectutils
 import (
 	"reflect"
 	"strings"
-	"sync"
 
 	"yunion.io/x/pkg/gotypes"
 	"yunion.io/x/pkg/utils"
@@ -32,6 +31,7 @@ type SStructFieldInfo struct {
 	FieldName   string
 	ForceString bool
 	Tags        map[string]string
+	Validate    SValidateTag
 }
 
 func ParseStructFieldJsonInfo(sf reflect.StructField) SStructFieldInfo {
@@ -79,6 +79,7 @@ func ParseStructFieldJsonInfo(sf reflect.StructField) SStructFieldInfo {
 	if val, ok := info.Tags["name"]; ok {
 		info.Name = val
 	}
+	info.Validate = parseValidateTag(info.Tags["validate"])
 	return info
 }
 
@@ -156,17 +157,7 @@ func fetchStructFieldValueSet(dataValue reflect.Value, allocatePtr bool) SStruct
 
 func (set SStructFieldValueSet) GetStructFieldIndex(name string) int {
 	for i := 0; i < len(set); i += 1 {
-		jsonInfo := set[i].Info
-		if jsonInfo.MarshalName() == name {
-			return i
-		}
-		if utils.CamelSplit(jsonInfo.FieldName, "_") == utils.CamelSplit(name, "_") {
-			return i
-		}
-		if jsonInfo.FieldName == name {
-			return i
-		}
-		if jsonInfo.FieldName == utils.Capitalize(name) {
+		if matchFieldName(&set[i].Info, name) {
 			return i
 		}
 	}
@@ -212,27 +203,20 @@ func (set SStructFieldValueSetV2) GetInterface(name string) (interface{}, bool)
 }
 
 func (set SStructFieldValueSetV2) getStructFieldIndex(name string) int {
-	index := -1
-	for i, jsonInfo := range set.Infos {
-		if jsonInfo.MarshalName() == name {
-			index = i
-			break
-		}
-		if utils.CamelSplit(jsonInfo.FieldName, "_") == utils.CamelSplit(name, "_") {
-			index = i
-			break
-		}
-		if jsonInfo.FieldName == name {
-			index = i
-			break
-		}
-		if jsonInfo.FieldName == utils.Capitalize(name) {
-			index = i
-			break
+	index, ok := set.indexOf[name]
+	if !ok {
+		// indexOf is only populated for cached (i.e. struct) field sets;
+		// fall back to a linear scan rather than reporting "not found".
+		for i := range set.Infos {
+			if matchFieldName(&set.Infos[i], name) {
+				index = i
+				ok = true
+				break
+			}
 		}
 	}
-	if index < 0 {
-		return index
+	if !ok {
+		return -1
 	}
 	if index >= len(set.Values) {
 		index = len(set.Values) - 1
@@ -248,8 +232,9 @@ func (set SStructFieldValueSetV2) getStructFieldIndex(name string) int {
 }
 
 type SStructFieldValueSetV2 struct {
-	Infos []SStructFieldInfo
-	Values []SStructFieldValueV2
+	Infos   []SStructFieldInfo
+	Values  []SStructFieldValueV2
+	indexOf map[string]int
 }
 
 type SStructFieldValueV2 struct {
@@ -258,26 +243,27 @@ type SStructFieldValueV2 struct {
 }
 
 func FetchStructFieldValueSetV2(dataValue reflect.Value) SStructFieldValueSetV2 {
-	infos := cachefetchStructFieldInfos(dataValue)
+	entry := cachefetchStructInfoEntry(dataValue)
 	values, _ := fetchStructFieldValueV2s(dataValue, false, 0)
-	return SStructFieldValueSetV2{infos, values}
+	return SStructFieldValueSetV2{entry.infos, values, entry.indexOf}
 }
 
 func FetchStructFieldValueSetForWriteV2(dataValue reflect.Value) SStructFieldValueSetV2 {
-	infos := cachefetchStructFieldInfos(dataValue)
+	entry := cachefetchStructInfoEntry(dataValue)
 	values, _ := fetchStructFieldValueV2s(dataValue, true, 0)
-	return SStructFieldValueSetV2{infos, values}
+	return SStructFieldValueSetV2{entry.infos, values, entry.indexOf}
 }
 
-var structFieldInfoCache sync.Map
-
-func cachefetchStructFieldInfos(dataValue reflect.Value) []SStructFieldInfo {
+func cachefetchStructInfoEntry(dataValue reflect.Value) *structInfoCacheEntry {
 	dataType := dataValue.Type()
-	if r, ok := structFieldInfoCache.Load(dataType); ok {
-		return r.([]SStructFieldInfo)
-	}
-	f, _ := structFieldInfoCache.LoadOrStore(dataType, fetchStructFieldInfos(dataValue))
-	return f.([]SStructFieldInfo)
+	return structInfoCache.get(dataType, func() *structInfoCacheEntry {
+		infos := fetchStructFieldInfos(dataValue)
+		return &structInfoCacheEntry{
+			typ:     dataType,
+			infos:   infos,
+			indexOf: buildStructFieldIndex(infos),
+		}
+	})
 }
 
 func fetchStructFieldInfos(dataValue reflect.Value) []SStructFieldInfo {
@@ -295,18 +281,19 @@ func fetchStructFieldInfos(dataValue reflect.Value) []SStructFieldInfo {
 			}
 			if fv.Kind() == reflect.Ptr {
 				if fv.IsNil() {
-					fv.Set(reflect.New(fv.Type().Elem()))
+					fv = reflect.New(fv.Type().Elem()).Elem()
+				} else {
+					fv = fv.Elem()
 				}
-				fv = fv.Elem()
 			}
 			if fv.Kind() == reflect.Interface {
 				fv = fv.Elem()
 			}
-			if fv.Kind() == reflect.Struct && sf.Type != gotypes.TimeType{
-			 	subInfo := fetchStructFieldInfos(fv)
-			 	ret = append(ret, subInfo...)
-			 	continue
-		 }
+			if fv.Kind() == reflect.Struct && sf.Type != gotypes.TimeType {
+				subInfo := fetchStructFieldInfos(fv)
+				ret = append(ret, subInfo...)
+				continue
+			}
 		}
 		jsonInfo := ParseStructFieldJsonInfo(sf)
 		ret = append(ret, jsonInfo)
@@ -333,6 +320,14 @@ func fetchStructFieldValueV2s(dataValue reflect.Value, allocatePtr bool, index i
 				if fv.IsNil() {
 					if fv.Kind() == reflect.Ptr && allocatePtr {
 						fv.Set(reflect.New(fv.Type().Elem()))
+					} else if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+						// fetchStructFieldInfos still expands a nil embedded
+						// pointer's fields into Infos, so advance index by
+						// the same width it recurses to even though there is
+						// no real value to report for them: discard the
+						// recursion's (fabricated) values, keep its index.
+						_, index = fetchStructFieldValueV2s(reflect.New(fv.Type().Elem()).Elem(), allocatePtr, index)
+						continue
 					} else {
 						index += 1
 						continue
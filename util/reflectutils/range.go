@@ -0,0 +1,140 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reflectutils
+
+import (
+	"reflect"
+
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/utils"
+)
+
+// RangeStructFields walks the exported fields of the struct value v,
+// reusing the same per-type SStructFieldInfo cache as FetchStructFieldValueSetV2,
+// and calls fn once per leaf field with its info and current value. It
+// recurses into anonymous embedded structs without ever materializing an
+// intermediate SStructFieldValueSet slice, which cuts allocations well below
+// FetchStructFieldValueSetV2 (see BenchmarkRangeStructFields) though
+// reflect.Type.Field still allocates an Index slice for each field visited,
+// so this is low-allocation rather than allocation-free. fn returning false
+// stops the walk early, in which case RangeStructFields also returns false.
+func RangeStructFields(v reflect.Value, fn func(info *SStructFieldInfo, val reflect.Value) bool) bool {
+	entry := cachefetchStructInfoEntry(v)
+	idx := 0
+	return rangeStructFields(v, entry.infos, &idx, fn)
+}
+
+// rangeStructFields must visit fields in exactly the same order, and make
+// exactly the same descend-vs-leaf decisions, as fetchStructFieldInfos: the
+// idx cursor walks infos (cached once per type) in lockstep with this live
+// traversal of dataValue (specific to the instance being ranged over).
+func rangeStructFields(dataValue reflect.Value, infos []SStructFieldInfo, idx *int, fn func(*SStructFieldInfo, reflect.Value) bool) bool {
+	dataType := dataValue.Type()
+	for i := 0; i < dataType.NumField(); i += 1 {
+		sf := dataType.Field(i)
+		if !gotypes.IsFieldExportable(sf.Name) {
+			continue
+		}
+		fv := dataValue.Field(i)
+		if sf.Anonymous {
+			if !fv.IsValid() {
+				continue
+			}
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					// Read-only traversal: descend into a detached zero
+					// value instead of fv.Set'ing the real field, which
+					// would mutate the caller's struct and panic when
+					// dataValue isn't addressable.
+					fv = reflect.New(fv.Type().Elem()).Elem()
+				} else {
+					fv = fv.Elem()
+				}
+			}
+			if fv.Kind() == reflect.Interface {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && sf.Type != gotypes.TimeType {
+				if !rangeStructFields(fv, infos, idx, fn) {
+					return false
+				}
+				continue
+			}
+		}
+		info := &infos[*idx]
+		*idx += 1
+		if !fn(info, fv) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchFieldName reports whether name refers to info under any of the
+// aliases GetStructFieldIndex accepts: its marshal (json) name, its
+// snake_case form, its raw Go field name, or the capitalized form of name.
+func matchFieldName(info *SStructFieldInfo, name string) bool {
+	if info.MarshalName() == name {
+		return true
+	}
+	if utils.CamelSplit(info.FieldName, "_") == utils.CamelSplit(name, "_") {
+		return true
+	}
+	if info.FieldName == name {
+		return true
+	}
+	if info.FieldName == utils.Capitalize(name) {
+		return true
+	}
+	return false
+}
+
+// RangeStructField looks up the single field named name on v, calling fn
+// with its info and value as soon as a match is found and stopping the walk
+// immediately, rather than materializing a full SStructFieldValueSetV2 just
+// to discard every field but one. It reports whether a matching field was
+// found.
+func RangeStructField(v reflect.Value, name string, fn func(info *SStructFieldInfo, val reflect.Value)) bool {
+	found := false
+	RangeStructFields(v, func(info *SStructFieldInfo, val reflect.Value) bool {
+		if !matchFieldName(info, name) {
+			return true
+		}
+		fn(info, val)
+		found = true
+		return false
+	})
+	return found
+}
+
+// GetValue looks up the field named name on v's underlying struct, using
+// RangeStructField's early-exit walk instead of building a full field/value
+// set when only a single field is needed.
+func GetValue(v reflect.Value, name string) (reflect.Value, bool) {
+	var result reflect.Value
+	found := RangeStructField(v, name, func(info *SStructFieldInfo, val reflect.Value) {
+		result = val
+	})
+	return result, found
+}
+
+// GetInterface is GetValue followed by Value.Interface.
+func GetInterface(v reflect.Value, name string) (interface{}, bool) {
+	val, ok := GetValue(v, name)
+	if !ok || !val.CanInterface() {
+		return nil, false
+	}
+	return val.Interface(), true
+}
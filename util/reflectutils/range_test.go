@@ -0,0 +1,203 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reflectutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+type SRangeLevel3 struct {
+	C1  int `json:"c1"`
+	C2  int `json:"c2"`
+	C3  int `json:"c3"`
+	C4  int `json:"c4"`
+	C5  int `json:"c5"`
+	C6  int `json:"c6"`
+	C7  int `json:"c7"`
+	C8  int `json:"c8"`
+	C9  int `json:"c9"`
+	C10 int `json:"c10"`
+	C11 int `json:"c11"`
+	C12 int `json:"c12"`
+	C13 int `json:"c13"`
+	C14 int `json:"c14"`
+	C15 int `json:"c15"`
+	C16 int `json:"c16"`
+	C17 int `json:"c17"`
+	C18 int `json:"c18"`
+	C19 int `json:"c19"`
+	C20 int `json:"c20"`
+}
+
+type SRangeLevel2 struct {
+	SRangeLevel3
+	B1  int `json:"b1"`
+	B2  int `json:"b2"`
+	B3  int `json:"b3"`
+	B4  int `json:"b4"`
+	B5  int `json:"b5"`
+	B6  int `json:"b6"`
+	B7  int `json:"b7"`
+	B8  int `json:"b8"`
+	B9  int `json:"b9"`
+	B10 int `json:"b10"`
+	B11 int `json:"b11"`
+	B12 int `json:"b12"`
+	B13 int `json:"b13"`
+	B14 int `json:"b14"`
+	B15 int `json:"b15"`
+}
+
+type SRangeLevel1 struct {
+	SRangeLevel2
+	A1  int `json:"a1"`
+	A2  int `json:"a2"`
+	A3  int `json:"a3"`
+	A4  int `json:"a4"`
+	A5  int `json:"a5"`
+	A6  int `json:"a6"`
+	A7  int `json:"a7"`
+	A8  int `json:"a8"`
+	A9  int `json:"a9"`
+	A10 int `json:"a10"`
+	A11 int `json:"a11"`
+	A12 int `json:"a12"`
+	A13 int `json:"a13"`
+	A14 int `json:"a14"`
+	A15 int `json:"a15"`
+}
+
+type SRangeBenchStruct struct {
+	SRangeLevel1
+	Name string `json:"name"`
+}
+
+func TestRangeStructFields(t *testing.T) {
+	s := SRangeBenchStruct{Name: "x"}
+	s.A1 = 1
+	s.B1 = 2
+	s.C1 = 3
+
+	count := 0
+	names := map[string]bool{}
+	RangeStructFields(reflect.ValueOf(&s).Elem(), func(info *SStructFieldInfo, val reflect.Value) bool {
+		count += 1
+		names[info.MarshalName()] = true
+		return true
+	})
+
+	set := FetchStructFieldValueSetV2(reflect.ValueOf(&s).Elem())
+	if count != len(set.Values) {
+		t.Fatalf("RangeStructFields visited %d fields, V2 set has %d", count, len(set.Values))
+	}
+	for _, want := range []string{"name", "a1", "b1", "c1"} {
+		if !names[want] {
+			t.Errorf("RangeStructFields did not visit field %q", want)
+		}
+	}
+}
+
+func TestRangeStructFieldsEarlyExit(t *testing.T) {
+	s := SRangeBenchStruct{}
+	seen := 0
+	RangeStructFields(reflect.ValueOf(&s).Elem(), func(info *SStructFieldInfo, val reflect.Value) bool {
+		seen += 1
+		return false
+	})
+	if seen != 1 {
+		t.Fatalf("expected walk to stop after first field, visited %d", seen)
+	}
+}
+
+func TestRangeStructFieldAndGetValue(t *testing.T) {
+	s := SRangeBenchStruct{Name: "hello"}
+	s.B7 = 42
+
+	v := reflect.ValueOf(&s).Elem()
+
+	found := RangeStructField(v, "b7", func(info *SStructFieldInfo, val reflect.Value) {
+		if val.Int() != 42 {
+			t.Errorf("expected 42, got %d", val.Int())
+		}
+	})
+	if !found {
+		t.Fatalf("expected to find field b7")
+	}
+
+	val, ok := GetValue(v, "name")
+	if !ok || val.String() != "hello" {
+		t.Fatalf("GetValue(name) = %v, %v", val, ok)
+	}
+
+	iface, ok := GetInterface(v, "name")
+	if !ok || iface.(string) != "hello" {
+		t.Fatalf("GetInterface(name) = %v, %v", iface, ok)
+	}
+
+	if _, ok := GetValue(v, "nosuchfield"); ok {
+		t.Fatalf("expected GetValue to report not found for an unknown field")
+	}
+}
+
+type sRangeNilPtrOuter struct {
+	*SRangeLevel3
+	Y int `json:"y"`
+}
+
+func TestRangeStructFieldsNilAnonymousPointer(t *testing.T) {
+	outer := sRangeNilPtrOuter{Y: 7}
+
+	// Not addressable: must not panic even though the embedded *SRangeLevel3 is nil.
+	val, ok := GetValue(reflect.ValueOf(outer), "y")
+	if !ok || val.Int() != 7 {
+		t.Fatalf("GetValue(y) on non-addressable value = %v, %v", val, ok)
+	}
+	if outer.SRangeLevel3 != nil {
+		t.Fatalf("GetValue must not allocate into the caller's struct, got %+v", outer.SRangeLevel3)
+	}
+
+	c1, ok := GetValue(reflect.ValueOf(outer), "c1")
+	if !ok || c1.Int() != 0 {
+		t.Fatalf("GetValue(c1) through a nil embedded pointer = %v, %v, want zero value", c1, ok)
+	}
+	if outer.SRangeLevel3 != nil {
+		t.Fatalf("GetValue must not allocate into the caller's struct, got %+v", outer.SRangeLevel3)
+	}
+}
+
+func BenchmarkFetchStructFieldValueSetV2(b *testing.B) {
+	s := SRangeBenchStruct{Name: "x"}
+	v := reflect.ValueOf(&s).Elem()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		set := FetchStructFieldValueSetV2(v)
+		for j := range set.Values {
+			_ = set.Values[j].Value
+		}
+	}
+}
+
+func BenchmarkRangeStructFields(b *testing.B) {
+	s := SRangeBenchStruct{Name: "x"}
+	v := reflect.ValueOf(&s).Elem()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		RangeStructFields(v, func(info *SStructFieldInfo, val reflect.Value) bool {
+			_ = val
+			return true
+		})
+	}
+}
@@ -0,0 +1,412 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reflectutils
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"yunion.io/x/pkg/gotypes"
+)
+
+// SValidateRule is a single comma-separated element of a `validate:"..."`
+// tag, e.g. "min=3" parses to Tag:"min" Param:"3", and a bare "required"
+// parses to Tag:"required" Param:"".
+type SValidateRule struct {
+	Tag   string
+	Param string
+}
+
+// SValidateTag is the parsed form of a struct field's `validate:"..."` tag.
+// Rules apply to the field itself; if Dive is set the field is expected to
+// be a slice, array or map, and ElemRules apply to each of its elements
+// instead, mirroring the "dive" marker of the go-playground/validator tag
+// language.
+type SValidateTag struct {
+	Raw       string
+	Rules     []SValidateRule
+	Dive      bool
+	ElemRules []SValidateRule
+}
+
+func parseValidateTag(tag string) SValidateTag {
+	vt := SValidateTag{Raw: tag}
+	if len(tag) == 0 {
+		return vt
+	}
+	rules := &vt.Rules
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		if part == "dive" {
+			vt.Dive = true
+			rules = &vt.ElemRules
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		rule := SValidateRule{Tag: kv[0]}
+		if len(kv) > 1 {
+			rule.Param = kv[1]
+		}
+		*rules = append(*rules, rule)
+	}
+	return vt
+}
+
+// FieldLevel exposes the value and context a registered validator function
+// needs to decide whether a field passes its rule.
+type FieldLevel interface {
+	// Field is the value under validation (the field itself, or, for a
+	// diving rule, one element of it).
+	Field() reflect.Value
+	// FieldName is the Go struct field name.
+	FieldName() string
+	// Param is the rule parameter, e.g. "3" for "min=3".
+	Param() string
+	// Parent is the struct value the field belongs to.
+	Parent() reflect.Value
+	// Context is the context passed to ValidateWithContext, or
+	// context.Background() for plain Validate calls.
+	Context() context.Context
+}
+
+type sFieldLevel struct {
+	ctx       context.Context
+	parent    reflect.Value
+	field     reflect.Value
+	fieldName string
+	param     string
+}
+
+func (fl *sFieldLevel) Field() reflect.Value     { return fl.field }
+func (fl *sFieldLevel) FieldName() string        { return fl.fieldName }
+func (fl *sFieldLevel) Param() string            { return fl.param }
+func (fl *sFieldLevel) Parent() reflect.Value    { return fl.parent }
+func (fl *sFieldLevel) Context() context.Context { return fl.ctx }
+
+// ValidatorFunc reports whether the field under validation satisfies a rule.
+type ValidatorFunc func(fl FieldLevel) bool
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[string]ValidatorFunc{}
+)
+
+// RegisterValidator registers a validator function under a validate tag
+// name, e.g. RegisterValidator("phone", isPhoneNumber). Registering a tag
+// that is already registered replaces it, which lets callers override the
+// built-ins.
+func RegisterValidator(tag string, fn ValidatorFunc) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[tag] = fn
+}
+
+func getValidator(tag string) (ValidatorFunc, bool) {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+	fn, ok := validators[tag]
+	return fn, ok
+}
+
+func init() {
+	RegisterValidator("required", validateRequired)
+	RegisterValidator("min", validateMin)
+	RegisterValidator("max", validateMax)
+	RegisterValidator("gte", validateGte)
+	RegisterValidator("lte", validateLte)
+	RegisterValidator("len", validateLen)
+	RegisterValidator("oneof", validateOneof)
+	RegisterValidator("regexp", validateRegexpTag)
+	RegisterValidator("email", validateEmail)
+	RegisterValidator("url", validateURL)
+}
+
+func validateRequired(fl FieldLevel) bool {
+	fv := fl.Field()
+	return fv.IsValid() && !fv.IsZero()
+}
+
+func fieldLength(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fv.Len()), true
+	}
+	return 0, false
+}
+
+func fieldNumber(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), true
+	}
+	return 0, false
+}
+
+func compareBound(fl FieldLevel, cmp func(val, bound float64) bool) bool {
+	bound, err := strconv.ParseFloat(fl.Param(), 64)
+	if err != nil {
+		return false
+	}
+	if n, ok := fieldNumber(fl.Field()); ok {
+		return cmp(n, bound)
+	}
+	if n, ok := fieldLength(fl.Field()); ok {
+		return cmp(n, bound)
+	}
+	return false
+}
+
+func validateMin(fl FieldLevel) bool {
+	return compareBound(fl, func(val, bound float64) bool { return val >= bound })
+}
+
+func validateMax(fl FieldLevel) bool {
+	return compareBound(fl, func(val, bound float64) bool { return val <= bound })
+}
+
+func validateGte(fl FieldLevel) bool {
+	return compareBound(fl, func(val, bound float64) bool { return val >= bound })
+}
+
+func validateLte(fl FieldLevel) bool {
+	return compareBound(fl, func(val, bound float64) bool { return val <= bound })
+}
+
+func validateLen(fl FieldLevel) bool {
+	want, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		return false
+	}
+	if n, ok := fieldLength(fl.Field()); ok {
+		return int(n) == want
+	}
+	return false
+}
+
+func validateOneof(fl FieldLevel) bool {
+	fv := fl.Field()
+	var s string
+	switch {
+	case fv.Kind() == reflect.String:
+		s = fv.String()
+	default:
+		if n, ok := fieldNumber(fv); ok {
+			s = strconv.FormatFloat(n, 'f', -1, 64)
+		} else {
+			return false
+		}
+	}
+	for _, opt := range strings.Fields(fl.Param()) {
+		if opt == s {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRegexpTag(fl FieldLevel) bool {
+	fv := fl.Field()
+	if fv.Kind() != reflect.String {
+		return false
+	}
+	re, err := regexp.Compile(fl.Param())
+	if err != nil {
+		return false
+	}
+	return re.MatchString(fv.String())
+}
+
+func validateEmail(fl FieldLevel) bool {
+	fv := fl.Field()
+	if fv.Kind() != reflect.String {
+		return false
+	}
+	if fv.String() == "" {
+		return true
+	}
+	_, err := mail.ParseAddress(fv.String())
+	return err == nil
+}
+
+func validateURL(fl FieldLevel) bool {
+	fv := fl.Field()
+	if fv.Kind() != reflect.String {
+		return false
+	}
+	if fv.String() == "" {
+		return true
+	}
+	u, err := url.ParseRequestURI(fv.String())
+	return err == nil && u.Scheme != ""
+}
+
+// ValidationError describes a single failed validation rule on a field.
+type ValidationError struct {
+	Field   string
+	Tag     string
+	Param   string
+	Value   interface{}
+	Message string
+}
+
+// ValidationErrors collects every ValidationError found by Validate or
+// ValidateWithContext. It implements error so it can be returned directly.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate walks v (a struct, or pointer to struct) and applies the
+// `validate:"..."` rules parsed from each field's SStructFieldInfo,
+// returning a ValidationErrors describing every rule that failed, or nil if
+// v is valid. The struct is walked via the same SStructFieldValueSetV2 cache
+// used by JSON marshalling, so validation does not re-parse tags.
+func Validate(v interface{}) error {
+	return ValidateWithContext(context.Background(), v)
+}
+
+// ValidateWithContext is Validate, but the given context is made available
+// to validator functions via FieldLevel.Context.
+func ValidateWithContext(ctx context.Context, v interface{}) error {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return fmt.Errorf("reflectutils.Validate: expecting struct, got %s", val.Kind())
+	}
+	var errs ValidationErrors
+	validateStruct(ctx, val, &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateStruct(ctx context.Context, val reflect.Value, errs *ValidationErrors) {
+	set := FetchStructFieldValueSetV2(val)
+	// set.Values is not index-aligned with set.Infos: a nil, unallocated
+	// anonymous embedded pointer contributes no entries to Values even
+	// though fetchStructFieldInfos still expands its fields into Infos.
+	// fetchStructFieldValueV2s advances its index by that same width, so
+	// each SStructFieldValueV2.Index names the Infos entry it actually
+	// corresponds to. Look fields up that way rather than by MarshalName,
+	// which can't distinguish fields that share a name (e.g. an outer field
+	// shadowing one promoted from an embedded struct).
+	for _, fv := range set.Values {
+		if fv.Index < 0 || fv.Index >= len(set.Infos) {
+			continue
+		}
+		validateField(ctx, val, set.Infos[fv.Index], fv.Value, errs)
+	}
+}
+
+func validateField(ctx context.Context, parent reflect.Value, info SStructFieldInfo, fv reflect.Value, errs *ValidationErrors) {
+	name := info.MarshalName()
+	for _, rule := range info.Validate.Rules {
+		runValidator(ctx, parent, info.FieldName, name, fv, rule, errs)
+	}
+
+	kind := fv.Kind()
+	for kind == reflect.Ptr || kind == reflect.Interface {
+		if fv.IsNil() {
+			return
+		}
+		fv = fv.Elem()
+		kind = fv.Kind()
+	}
+
+	switch kind {
+	case reflect.Struct:
+		if fv.Type() != gotypes.TimeType {
+			validateStruct(ctx, fv, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if info.Validate.Dive {
+			for i := 0; i < fv.Len(); i += 1 {
+				validateElem(ctx, parent, info, fv.Index(i), errs)
+			}
+		}
+	case reflect.Map:
+		if info.Validate.Dive {
+			for _, key := range fv.MapKeys() {
+				validateElem(ctx, parent, info, fv.MapIndex(key), errs)
+			}
+		}
+	}
+}
+
+func validateElem(ctx context.Context, parent reflect.Value, info SStructFieldInfo, elem reflect.Value, errs *ValidationErrors) {
+	name := info.MarshalName()
+	for _, rule := range info.Validate.ElemRules {
+		runValidator(ctx, parent, info.FieldName, name, elem, rule, errs)
+	}
+
+	kind := elem.Kind()
+	for kind == reflect.Ptr || kind == reflect.Interface {
+		if elem.IsNil() {
+			return
+		}
+		elem = elem.Elem()
+		kind = elem.Kind()
+	}
+	if kind == reflect.Struct && elem.Type() != gotypes.TimeType {
+		validateStruct(ctx, elem, errs)
+	}
+}
+
+func runValidator(ctx context.Context, parent reflect.Value, fieldName, name string, fv reflect.Value, rule SValidateRule, errs *ValidationErrors) {
+	fn, ok := getValidator(rule.Tag)
+	if !ok {
+		return
+	}
+	fl := &sFieldLevel{ctx: ctx, parent: parent, field: fv, fieldName: fieldName, param: rule.Param}
+	if fn(fl) {
+		return
+	}
+	var val interface{}
+	if fv.IsValid() && fv.CanInterface() {
+		val = fv.Interface()
+	}
+	*errs = append(*errs, ValidationError{
+		Field:   name,
+		Tag:     rule.Tag,
+		Param:   rule.Param,
+		Value:   val,
+		Message: fmt.Sprintf("%s failed on the %q tag", name, rule.Tag),
+	})
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reflectutils
+
+import (
+	"testing"
+)
+
+type sAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type sValidateUser struct {
+	Name    string   `json:"name" validate:"required,min=2,max=10"`
+	Age     int      `json:"age" validate:"gte=0,lte=150"`
+	Role    string   `json:"role" validate:"oneof=admin member guest"`
+	Email   string   `json:"email" validate:"email"`
+	Tags    []string `json:"tags" validate:"dive,min=1"`
+	Address sAddress `json:"address"`
+}
+
+func TestValidate(t *testing.T) {
+	good := sValidateUser{
+		Name:  "alice",
+		Age:   30,
+		Role:  "admin",
+		Email: "alice@example.com",
+		Tags:  []string{"a", "b"},
+		Address: sAddress{
+			City: "Beijing",
+		},
+	}
+	if err := Validate(&good); err != nil {
+		t.Fatalf("expected valid struct, got error: %s", err)
+	}
+
+	bad := sValidateUser{
+		Name:  "a",
+		Age:   200,
+		Role:  "root",
+		Email: "not-an-email",
+		Tags:  []string{""},
+		Address: sAddress{
+			City: "",
+		},
+	}
+	err := Validate(&bad)
+	if err == nil {
+		t.Fatalf("expected validation errors, got nil")
+	}
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %T", err)
+	}
+	wantTags := map[string]bool{
+		"min": false, "lte": false, "oneof": false, "email": false, "required": false,
+	}
+	for _, e := range errs {
+		if _, ok := wantTags[e.Tag]; ok {
+			wantTags[e.Tag] = true
+		}
+	}
+	for tag, found := range wantTags {
+		if !found {
+			t.Errorf("expected a validation error for tag %q, errs: %v", tag, errs)
+		}
+	}
+}
+
+type SValidateNilInner struct {
+	A string `json:"a" validate:"required"`
+	B string `json:"b" validate:"required"`
+}
+
+type sValidateNilOuter struct {
+	*SValidateNilInner
+	C string `json:"c" validate:"required"`
+}
+
+func TestValidateNilEmbeddedPointer(t *testing.T) {
+	ResetStructInfoCache()
+
+	check := func(step string) {
+		err := Validate(&sValidateNilOuter{})
+		if err == nil {
+			t.Fatalf("%s: expected a validation error for the empty required field C", step)
+		}
+		errs, ok := err.(ValidationErrors)
+		if !ok {
+			t.Fatalf("%s: expected ValidationErrors, got %T", step, err)
+		}
+		requiredC := false
+		for _, e := range errs {
+			if e.Field == "a" || e.Field == "b" {
+				t.Errorf("%s: field %q has no backing value through the nil embed and must not be validated, got %v", step, e.Field, errs)
+			}
+			if e.Field == "c" && e.Tag == "required" {
+				requiredC = true
+			}
+		}
+		if !requiredC {
+			t.Errorf("%s: expected a required error on field c, got %v", step, errs)
+		}
+	}
+
+	// First call misses the struct field info cache, second hits it: the nil
+	// embedded *SValidateNilInner must not desync Infos from Values either way.
+	check("cache miss")
+	check("cache hit")
+}
+
+type SValidateShadowInner struct {
+	Name string `json:"name" validate:"min=5"`
+}
+
+type sValidateShadowOuter struct {
+	SValidateShadowInner
+	Name string `json:"name" validate:"min=5"`
+}
+
+func TestValidateShadowedFieldName(t *testing.T) {
+	ResetStructInfoCache()
+
+	// The outer Name shadows the embedded one but both are validated
+	// independently: resolving fields by MarshalName would collapse them
+	// onto whichever field the (first-wins) name index points at.
+	good := sValidateShadowOuter{
+		SValidateShadowInner: SValidateShadowInner{Name: "zzzzz"},
+		Name:                 "aaaaa",
+	}
+	if err := Validate(&good); err != nil {
+		t.Fatalf("expected both 5-char names to pass, got error: %s", err)
+	}
+
+	outerTooShort := sValidateShadowOuter{
+		SValidateShadowInner: SValidateShadowInner{Name: "zzzzz"},
+		Name:                 "a",
+	}
+	err := Validate(&outerTooShort)
+	if err == nil {
+		t.Fatalf("expected the outer Name's own min=5 rule to fail, got nil")
+	}
+
+	innerTooShort := sValidateShadowOuter{
+		SValidateShadowInner: SValidateShadowInner{Name: "z"},
+		Name:                 "aaaaa",
+	}
+	err = Validate(&innerTooShort)
+	if err == nil {
+		t.Fatalf("expected the embedded Name's own min=5 rule to fail, got nil")
+	}
+}
+
+func TestRegisterValidator(t *testing.T) {
+	RegisterValidator("even", func(fl FieldLevel) bool {
+		n, ok := fieldNumber(fl.Field())
+		return ok && int64(n)%2 == 0
+	})
+
+	type sEven struct {
+		N int `validate:"even"`
+	}
+
+	if err := Validate(&sEven{N: 2}); err != nil {
+		t.Errorf("expected 2 to be even, got error: %s", err)
+	}
+	if err := Validate(&sEven{N: 3}); err == nil {
+		t.Errorf("expected 3 to fail the even validator")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeutils
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// apacheClfLayout is the timestamp format used by the Apache Combined Log
+// Format, e.g. "10/Oct/2019:13:55:36 -0700".
+const apacheClfLayout = "02/Jan/2006:15:04:05 -0700"
+
+func TestRegisterTimeFormat(t *testing.T) {
+	RegisterTimeFormat(&TimeFormat{
+		Name:    "apache_clf",
+		Layout:  apacheClfLayout,
+		Matcher: regexp.MustCompile(`^\d{2}/[A-Za-z]{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}$`),
+		Parse: func(s string) (time.Time, error) {
+			return time.Parse(apacheClfLayout, s)
+		},
+	})
+
+	in := "10/Oct/2019:13:55:36 -0700"
+	tm, err := ParseTimeStr(in)
+	if err != nil {
+		t.Fatalf("ParseTimeStr(%q) failed: %v", in, err)
+	}
+	want, _ := time.Parse(apacheClfLayout, in)
+	if !tm.Equal(want) {
+		t.Errorf("got %s, want %s", tm, want)
+	}
+
+	name, err := DetectTimeFormat(in)
+	if err != nil {
+		t.Fatalf("DetectTimeFormat(%q) failed: %v", in, err)
+	}
+	if name != "apache_clf" {
+		t.Errorf("detected format %q, want %q", name, "apache_clf")
+	}
+
+	tm2, err := ParseTimeStrWithFormat("apache_clf", in)
+	if err != nil {
+		t.Fatalf("ParseTimeStrWithFormat failed: %v", err)
+	}
+	if !tm2.Equal(want) {
+		t.Errorf("got %s, want %s", tm2, want)
+	}
+}
+
+func TestParseTimeStrUnixAndRelative(t *testing.T) {
+	now := time.Now().UTC()
+
+	sec := now.Unix()
+	tm, err := ParseTimeStr(strconv.FormatInt(sec, 10))
+	if err != nil {
+		t.Fatalf("ParseTimeStr(unix seconds) failed: %v", err)
+	}
+	if tm.Sub(now.Truncate(time.Second)) != 0 {
+		t.Errorf("unix seconds round-trip mismatch: got %s, want %s", tm, now)
+	}
+
+	cases := []struct {
+		in    string
+		delta time.Duration
+	}{
+		{"2h30m ago", -(2*time.Hour + 30*time.Minute)},
+		{"in 15m", 15 * time.Minute},
+	}
+	for _, c := range cases {
+		before := time.Now()
+		got, err := ParseTimeStr(c.in)
+		if err != nil {
+			t.Fatalf("ParseTimeStr(%q) failed: %v", c.in, err)
+		}
+		want := before.Add(c.delta)
+		if d := got.Sub(want); d < -time.Second || d > time.Second {
+			t.Errorf("%q: got %s, want close to %s", c.in, got, want)
+		}
+	}
+}
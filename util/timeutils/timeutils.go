@@ -0,0 +1,302 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeutils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IsoTimeFormat         = "2006-01-02T15:04:05Z"
+	IsoNoSecondTimeFormat = "2006-01-02T15:04Z"
+	FullIsoTimeFormat     = "2006-01-02T15:04:05.999999Z"
+	MysqlTimeFormat       = "2006-01-02 15:04:05"
+	CompactTimeFormat     = "20060102150405"
+	ShortDateFormat       = "20060102"
+	DateFormat            = "2006-01-02"
+	RFC2882TimeFormat     = "Mon, 02 Jan 2006 15:04:05 GMT"
+	ZStackTimeFormat      = "Jan 2, 2006 3:04:05 PM"
+	FullIsoNanoTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+)
+
+func IsoTime(t time.Time) string {
+	return t.UTC().Format(IsoTimeFormat)
+}
+
+func IsoNoSecondTime(t time.Time) string {
+	return t.UTC().Format(IsoNoSecondTimeFormat)
+}
+
+func FullIsoTime(t time.Time) string {
+	return t.UTC().Format(FullIsoTimeFormat)
+}
+
+func MysqlTime(t time.Time) string {
+	return t.Format(MysqlTimeFormat)
+}
+
+func CompactTime(t time.Time) string {
+	return t.Format(CompactTimeFormat)
+}
+
+func ShortDate(t time.Time) string {
+	return t.Format(ShortDateFormat)
+}
+
+func DateStr(t time.Time) string {
+	return t.Format(DateFormat)
+}
+
+func RFC2882Time(t time.Time) string {
+	return t.UTC().Format(RFC2882TimeFormat)
+}
+
+func ZStackTime(t time.Time) string {
+	return t.Format(ZStackTimeFormat)
+}
+
+func FullIsoNanoTime(t time.Time) string {
+	return t.Format(FullIsoNanoTimeFormat)
+}
+
+// toFullIsoNanoTimeFormat pads the fractional-second part of an ISO8601
+// timestamp to nine digits so it can be parsed with FullIsoNanoTimeFormat,
+// which expects a fixed-width nanosecond fraction.
+func toFullIsoNanoTimeFormat(s string) string {
+	dotIdx := strings.Index(s, ".")
+	if dotIdx < 0 {
+		return s
+	}
+	fracIdx := dotIdx + 1
+	end := fracIdx
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end += 1
+	}
+	frac := s[fracIdx:end]
+	if len(frac) >= 9 {
+		return s[:fracIdx+9] + s[end:]
+	}
+	return s[:end] + strings.Repeat("0", 9-len(frac)) + s[end:]
+}
+
+// TimeFormat describes a named time layout that ParseTimeStr knows how to
+// recognize and parse. Matcher is a cheap pre-filter used by DetectTimeFormat
+// and ParseTimeStr to avoid attempting every registered Parse func against
+// every input string.
+type TimeFormat struct {
+	Name    string
+	Layout  string
+	Matcher *regexp.Regexp
+	Parse   func(s string) (time.Time, error)
+	Format  func(t time.Time) string
+}
+
+var (
+	formatsMu   sync.RWMutex
+	formatOrder []string
+	formats     = map[string]*TimeFormat{}
+)
+
+// RegisterTimeFormat registers a TimeFormat so that ParseTimeStr,
+// ParseTimeStrWithFormat and DetectTimeFormat can recognize it. Formats are
+// tried by ParseTimeStr in registration order; registering a format with a
+// Name that is already registered replaces it in place.
+func RegisterTimeFormat(f *TimeFormat) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if _, ok := formats[f.Name]; !ok {
+		formatOrder = append(formatOrder, f.Name)
+	}
+	formats[f.Name] = f
+}
+
+func layoutFormat(name, layout string, matcher *regexp.Regexp) *TimeFormat {
+	return &TimeFormat{
+		Name:    name,
+		Layout:  layout,
+		Matcher: matcher,
+		Parse: func(s string) (time.Time, error) {
+			return time.Parse(layout, s)
+		},
+		Format: func(t time.Time) string {
+			return t.UTC().Format(layout)
+		},
+	}
+}
+
+func init() {
+	RegisterTimeFormat(layoutFormat("iso", IsoTimeFormat,
+		regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`)))
+
+	RegisterTimeFormat(&TimeFormat{
+		Name:    "mysql",
+		Layout:  MysqlTimeFormat,
+		Matcher: regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}$`),
+		Parse: func(s string) (time.Time, error) {
+			return time.Parse(MysqlTimeFormat, s)
+		},
+		Format: MysqlTime,
+	})
+
+	RegisterTimeFormat(&TimeFormat{
+		Name:    "compact",
+		Layout:  CompactTimeFormat,
+		Matcher: regexp.MustCompile(`^\d{14}$`),
+		Parse: func(s string) (time.Time, error) {
+			return time.Parse(CompactTimeFormat, s)
+		},
+		Format: CompactTime,
+	})
+
+	RegisterTimeFormat(&TimeFormat{
+		Name:    "zstack",
+		Layout:  ZStackTimeFormat,
+		Matcher: regexp.MustCompile(`^[A-Za-z]{3} \d{1,2}, \d{4} \d{1,2}:\d{2}:\d{2} [AP]M$`),
+		Parse: func(s string) (time.Time, error) {
+			return time.ParseInLocation(ZStackTimeFormat, s, time.Local)
+		},
+		Format: ZStackTime,
+	})
+
+	RegisterTimeFormat(&TimeFormat{
+		Name:    "fulliso",
+		Layout:  FullIsoNanoTimeFormat,
+		Matcher: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`),
+		Parse: func(s string) (time.Time, error) {
+			return time.ParseInLocation(FullIsoNanoTimeFormat, toFullIsoNanoTimeFormat(s), time.Local)
+		},
+		Format: FullIsoNanoTime,
+	})
+
+	RegisterTimeFormat(&TimeFormat{
+		Name:    "rfc3339nano",
+		Layout:  time.RFC3339Nano,
+		Matcher: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`),
+		Parse: func(s string) (time.Time, error) {
+			return time.Parse(time.RFC3339Nano, s)
+		},
+		Format: func(t time.Time) string {
+			return t.Format(time.RFC3339Nano)
+		},
+	})
+
+	RegisterTimeFormat(unixFormat("unix_seconds", `^\d{10}$`, time.Second))
+	RegisterTimeFormat(unixFormat("unix_millis", `^\d{13}$`, time.Millisecond))
+	RegisterTimeFormat(unixFormat("unix_micros", `^\d{16}$`, time.Microsecond))
+
+	RegisterTimeFormat(&TimeFormat{
+		Name:    "relative_duration",
+		Matcher: regexp.MustCompile(`^(in\s+\S.*|\S.*\s+ago)$`),
+		Parse:   parseRelativeDuration,
+	})
+}
+
+func unixFormat(name, pattern string, unit time.Duration) *TimeFormat {
+	matcher := regexp.MustCompile(pattern)
+	return &TimeFormat{
+		Name:    name,
+		Matcher: matcher,
+		Parse: func(s string) (time.Time, error) {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(0, n*int64(unit)).UTC(), nil
+		},
+		Format: func(t time.Time) string {
+			return strconv.FormatInt(t.UnixNano()/int64(unit), 10)
+		},
+	}
+}
+
+// parseRelativeDuration parses human-readable relative offsets such as
+// "2h30m ago" and "in 15m", expressed relative to time.Now().
+func parseRelativeDuration(s string) (time.Time, error) {
+	switch {
+	case strings.HasPrefix(s, "in "):
+		dur, err := time.ParseDuration(strings.TrimSpace(s[len("in "):]))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Now().Add(dur), nil
+	case strings.HasSuffix(s, " ago"):
+		dur, err := time.ParseDuration(strings.TrimSpace(s[:len(s)-len(" ago")]))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Now().Add(-dur), nil
+	default:
+		return time.Time{}, fmt.Errorf("not a relative duration: %q", s)
+	}
+}
+
+// ParseTimeStr tries every registered TimeFormat, in registration order,
+// until one successfully parses s. Use RegisterTimeFormat to add support for
+// additional layouts without modifying this package.
+func ParseTimeStr(tstr string) (time.Time, error) {
+	tstr = strings.TrimSpace(tstr)
+
+	formatsMu.RLock()
+	order := make([]string, len(formatOrder))
+	copy(order, formatOrder)
+	formatsMu.RUnlock()
+
+	for _, name := range order {
+		formatsMu.RLock()
+		f := formats[name]
+		formatsMu.RUnlock()
+		if f.Matcher != nil && !f.Matcher.MatchString(tstr) {
+			continue
+		}
+		tm, err := f.Parse(tstr)
+		if err == nil {
+			return tm, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time format %q", tstr)
+}
+
+// ParseTimeStrWithFormat parses tstr using only the registered format with
+// the given name, bypassing matcher-based auto-detection.
+func ParseTimeStrWithFormat(name, tstr string) (time.Time, error) {
+	formatsMu.RLock()
+	f, ok := formats[name]
+	formatsMu.RUnlock()
+	if !ok {
+		return time.Time{}, fmt.Errorf("unregistered time format %q", name)
+	}
+	return f.Parse(strings.TrimSpace(tstr))
+}
+
+// DetectTimeFormat returns the name of the first registered format whose
+// Matcher matches tstr, without attempting to parse it.
+func DetectTimeFormat(tstr string) (string, error) {
+	tstr = strings.TrimSpace(tstr)
+
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, name := range formatOrder {
+		f := formats[name]
+		if f.Matcher != nil && f.Matcher.MatchString(tstr) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no registered time format matches %q", tstr)
+}